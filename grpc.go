@@ -0,0 +1,65 @@
+package efuse
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// GRPCKeyFunc selects the ID of the fuse in a Registry that should guard a unary gRPC call,
+// typically by method name.
+type GRPCKeyFunc func(method string) string
+
+// UnaryClientInterceptor returns a grpc.UnaryClientInterceptor that guards outgoing unary calls
+// with fuses from r, selected per-call by key. A call whose selected fuse is tripped is
+// rejected with ErrFuseOpen without reaching invoker; otherwise the result is pushed back via
+// PushState, treating a nil error as success. Calls for which key returns an ID with no
+// registered fuse pass through untouched.
+func UnaryClientInterceptor(r *Registry, key GRPCKeyFunc) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		fuse := r.Get(key(method))
+		if fuse == nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ok, err := fuse.GetState()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrFuseOpen
+		}
+
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		if pushErr := fuse.PushState(err == nil); pushErr != nil && err == nil {
+			return pushErr
+		}
+		return err
+	}
+}
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor that guards incoming unary calls
+// with fuses from r, selected per-call by key, mirroring UnaryClientInterceptor on the server
+// side.
+func UnaryServerInterceptor(r *Registry, key GRPCKeyFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		fuse := r.Get(key(info.FullMethod))
+		if fuse == nil {
+			return handler(ctx, req)
+		}
+
+		ok, err := fuse.GetState()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrFuseOpen
+		}
+
+		resp, err := handler(ctx, req)
+		if pushErr := fuse.PushState(err == nil); pushErr != nil && err == nil {
+			return resp, pushErr
+		}
+		return resp, err
+	}
+}
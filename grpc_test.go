@@ -0,0 +1,161 @@
+package efuse
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	tests := []struct {
+		name string
+
+		noFuseRegistered bool
+		getStateOK       bool
+		getStateErr      error
+		invokerErr       error
+
+		wantErr    error
+		wantPushed []bool
+	}{
+		{
+			name:             "no fuse registered passes through untouched",
+			noFuseRegistered: true,
+		},
+		{
+			name:       "open fuse rejects without reaching invoker",
+			getStateOK: false,
+			wantErr:    ErrFuseOpen,
+		},
+		{
+			name:        "GetState error propagates",
+			getStateErr: errors.New("store down"),
+			wantErr:     errors.New("store down"),
+		},
+		{
+			name:       "closed fuse pushes success",
+			getStateOK: true,
+			wantPushed: []bool{true},
+		},
+		{
+			name:       "closed fuse pushes failure when invoker errors",
+			getStateOK: true,
+			invokerErr: errors.New("unavailable"),
+			wantErr:    errors.New("unavailable"),
+			wantPushed: []bool{false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewRegistry()
+			fuse := &fakeFuse{id: "svc.Method", getStateOK: tt.getStateOK, getStateErr: tt.getStateErr}
+			if !tt.noFuseRegistered {
+				reg.Register(fuse)
+			}
+
+			invokerCalled := false
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				invokerCalled = true
+				return tt.invokerErr
+			}
+
+			interceptor := UnaryClientInterceptor(reg, func(method string) string { return method })
+			err := interceptor(context.Background(), "svc.Method", nil, nil, nil, invoker)
+
+			if (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("interceptor error = %v, want error presence %v", err, tt.wantErr != nil)
+			}
+			if tt.wantErr != nil && err.Error() != tt.wantErr.Error() {
+				t.Fatalf("interceptor error = %v, want %v", err, tt.wantErr)
+			}
+			if errors.Is(tt.wantErr, ErrFuseOpen) && invokerCalled {
+				t.Fatalf("interceptor reached invoker although the fuse is open")
+			}
+
+			if len(fuse.pushed) != len(tt.wantPushed) {
+				t.Fatalf("PushState called %v, want %v", fuse.pushed, tt.wantPushed)
+			}
+			for i, want := range tt.wantPushed {
+				if fuse.pushed[i] != want {
+					t.Errorf("pushed[%d] = %v, want %v", i, fuse.pushed[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name string
+
+		noFuseRegistered bool
+		getStateOK       bool
+		handlerErr       error
+
+		wantErr    error
+		wantPushed []bool
+	}{
+		{
+			name:             "no fuse registered passes through untouched",
+			noFuseRegistered: true,
+		},
+		{
+			name:       "open fuse rejects without reaching handler",
+			getStateOK: false,
+			wantErr:    ErrFuseOpen,
+		},
+		{
+			name:       "closed fuse pushes success",
+			getStateOK: true,
+			wantPushed: []bool{true},
+		},
+		{
+			name:       "closed fuse pushes failure when handler errors",
+			getStateOK: true,
+			handlerErr: errors.New("internal"),
+			wantErr:    errors.New("internal"),
+			wantPushed: []bool{false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewRegistry()
+			fuse := &fakeFuse{id: "/svc.Method", getStateOK: tt.getStateOK}
+			if !tt.noFuseRegistered {
+				reg.Register(fuse)
+			}
+
+			handlerCalled := false
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				handlerCalled = true
+				return nil, tt.handlerErr
+			}
+
+			interceptor := UnaryServerInterceptor(reg, func(method string) string { return method })
+			_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/svc.Method"}, handler)
+
+			if (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("interceptor error = %v, want error presence %v", err, tt.wantErr != nil)
+			}
+			if tt.wantErr != nil && err.Error() != tt.wantErr.Error() {
+				t.Fatalf("interceptor error = %v, want %v", err, tt.wantErr)
+			}
+			if errors.Is(tt.wantErr, ErrFuseOpen) && handlerCalled {
+				t.Fatalf("interceptor reached handler although the fuse is open")
+			}
+
+			if len(fuse.pushed) != len(tt.wantPushed) {
+				t.Fatalf("PushState called %v, want %v", fuse.pushed, tt.wantPushed)
+			}
+			for i, want := range tt.wantPushed {
+				if fuse.pushed[i] != want {
+					t.Errorf("pushed[%d] = %v, want %v", i, fuse.pushed[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package efuse
+
+import (
+	"testing"
+	"time"
+)
+
+type transition struct {
+	from, to State
+}
+
+// TestHooksFireAcrossFullRecoveryCycle drives a fuse through trip, half-open admission, and a
+// successful recovery, and checks OnStateChange fires for every transition (not just the
+// initial Closed->Open trip) and OnTrip fires exactly once, for the limit that actually tripped.
+func TestHooksFireAcrossFullRecoveryCycle(t *testing.T) {
+	var transitions []transition
+	var trips []TripReason
+
+	setting := PolyfuseSettings{
+		ID:                       "hooks",
+		Rampframe:                1,
+		Timeframe:                10,
+		MaxError:                 1,
+		OpenDuration:             10 * time.Millisecond,
+		HalfOpenMaxProbes:        1,
+		HalfOpenSuccessThreshold: 1,
+		OnStateChange: func(id string, from, to State) {
+			transitions = append(transitions, transition{from, to})
+		},
+		OnTrip: func(id string, reason TripReason) {
+			trips = append(trips, reason)
+		},
+	}
+	fuse := NewPolyfuse(setting).(*Polyfuse)
+	fuse.store.PushData(PolyfuseData{Request: 1, Error: 2, Timestamp: time.Now()})
+
+	// Closed -> Open
+	ok, err := fuse.GetState()
+	if err != nil {
+		t.Fatalf("GetState() error: %v", err)
+	}
+	if ok {
+		t.Fatalf("GetState() = true, want false after tripping")
+	}
+
+	time.Sleep(setting.OpenDuration + 5*time.Millisecond)
+
+	// Open -> HalfOpen (and admits the one allowed probe)
+	ok, err = fuse.GetState()
+	if err != nil {
+		t.Fatalf("GetState() error: %v", err)
+	}
+	if !ok {
+		t.Fatalf("GetState() = false, want true (should admit the half-open probe)")
+	}
+
+	// HalfOpen -> Closed
+	if err := fuse.PushState(true); err != nil {
+		t.Fatalf("PushState() error: %v", err)
+	}
+
+	want := []transition{
+		{StateClosed, StateOpen},
+		{StateOpen, StateHalfOpen},
+		{StateHalfOpen, StateClosed},
+	}
+	if len(transitions) != len(want) {
+		t.Fatalf("OnStateChange fired %d times: %+v, want %+v", len(transitions), transitions, want)
+	}
+	for i, w := range want {
+		if transitions[i] != w {
+			t.Errorf("transition[%d] = %+v, want %+v", i, transitions[i], w)
+		}
+	}
+
+	if len(trips) != 1 || trips[0] != TripMaxError {
+		t.Fatalf("OnTrip fired with %+v, want exactly one TripMaxError", trips)
+	}
+}
@@ -0,0 +1,53 @@
+package efuse
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrFuseOpen is returned by the RoundTripper HTTPMiddleware builds (and by the gRPC
+// interceptors) when the fuse selected for a call is tripped, rejecting the call without ever
+// reaching the upstream.
+var ErrFuseOpen = errors.New("efuse: fuse is open")
+
+// KeyFunc selects the ID of the fuse in a Registry that should guard req, e.g. by host or route.
+type KeyFunc func(req *http.Request) string
+
+// HTTPMiddleware returns a RoundTripper middleware that guards outgoing requests with fuses
+// from r, selected per-request by key. A request whose selected fuse is tripped is rejected
+// with ErrFuseOpen without reaching next; otherwise the result is pushed back via PushState,
+// treating a response status below 500 as success. Requests for which key returns an ID with
+// no registered fuse pass through untouched.
+func HTTPMiddleware(r *Registry, key KeyFunc) func(http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &fuseRoundTripper{next: next, registry: r, key: key}
+	}
+}
+
+type fuseRoundTripper struct {
+	next     http.RoundTripper
+	registry *Registry
+	key      KeyFunc
+}
+
+func (t *fuseRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	fuse := t.registry.Get(t.key(req))
+	if fuse == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	ok, err := fuse.GetState()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrFuseOpen
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	success := err == nil && resp.StatusCode < 500
+	if pushErr := fuse.PushState(success); pushErr != nil && err == nil {
+		return resp, pushErr
+	}
+	return resp, err
+}
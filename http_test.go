@@ -0,0 +1,134 @@
+package efuse
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeFuse is a minimal EFuse test double whose GetState/PushState results are directly
+// controllable and whose PushState calls are recorded, so HTTPMiddleware and the gRPC
+// interceptors can be tested without a real Polyfuse's trip arithmetic.
+type fakeFuse struct {
+	id string
+
+	getStateOK  bool
+	getStateErr error
+
+	pushed    []bool
+	pushedErr error
+}
+
+func (f *fakeFuse) GetID() string    { return f.id }
+func (f *fakeFuse) State() State     { return StateClosed }
+func (f *fakeFuse) GetState() (bool, error) {
+	return f.getStateOK, f.getStateErr
+}
+func (f *fakeFuse) PushState(state bool) error {
+	f.pushed = append(f.pushed, state)
+	return f.pushedErr
+}
+func (f *fakeFuse) PushStateWithLatency(state bool, _ time.Duration) error {
+	return f.PushState(state)
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestHTTPMiddleware(t *testing.T) {
+	tests := []struct {
+		name string
+
+		noFuseRegistered bool
+		getStateOK       bool
+		getStateErr      error
+		nextStatus       int
+		nextErr          error
+
+		wantErr    error
+		wantPushed []bool
+	}{
+		{
+			name:             "no fuse registered passes through untouched",
+			noFuseRegistered: true,
+			nextStatus:       http.StatusOK,
+		},
+		{
+			name:       "open fuse rejects without reaching next",
+			getStateOK: false,
+			nextStatus: http.StatusOK,
+			wantErr:    ErrFuseOpen,
+		},
+		{
+			name:        "GetState error propagates",
+			getStateErr: errors.New("store down"),
+			wantErr:     errors.New("store down"),
+		},
+		{
+			name:       "closed fuse pushes success on 2xx",
+			getStateOK: true,
+			nextStatus: http.StatusOK,
+			wantPushed: []bool{true},
+		},
+		{
+			name:       "closed fuse pushes failure on 5xx",
+			getStateOK: true,
+			nextStatus: http.StatusInternalServerError,
+			wantPushed: []bool{false},
+		},
+		{
+			name:       "closed fuse pushes failure when next errors",
+			getStateOK: true,
+			nextErr:    errors.New("dial tcp: refused"),
+			wantErr:    errors.New("dial tcp: refused"),
+			wantPushed: []bool{false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewRegistry()
+			fuse := &fakeFuse{id: "svc", getStateOK: tt.getStateOK, getStateErr: tt.getStateErr}
+			if !tt.noFuseRegistered {
+				reg.Register(fuse)
+			}
+
+			nextCalled := false
+			next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				nextCalled = true
+				if tt.nextErr != nil {
+					return nil, tt.nextErr
+				}
+				return &http.Response{StatusCode: tt.nextStatus}, nil
+			})
+
+			rt := HTTPMiddleware(reg, func(*http.Request) string { return "svc" })(next)
+			req, err := http.NewRequest(http.MethodGet, "http://example.invalid", nil)
+			if err != nil {
+				t.Fatalf("NewRequest() error: %v", err)
+			}
+
+			_, err = rt.RoundTrip(req)
+			if (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("RoundTrip() error = %v, want error presence %v", err, tt.wantErr != nil)
+			}
+			if tt.wantErr != nil && err.Error() != tt.wantErr.Error() {
+				t.Fatalf("RoundTrip() error = %v, want %v", err, tt.wantErr)
+			}
+			if errors.Is(tt.wantErr, ErrFuseOpen) && nextCalled {
+				t.Fatalf("RoundTrip() reached next although the fuse is open")
+			}
+
+			if len(fuse.pushed) != len(tt.wantPushed) {
+				t.Fatalf("PushState called %v, want %v", fuse.pushed, tt.wantPushed)
+			}
+			for i, want := range tt.wantPushed {
+				if fuse.pushed[i] != want {
+					t.Errorf("pushed[%d] = %v, want %v", i, fuse.pushed[i], want)
+				}
+			}
+		})
+	}
+}
@@ -1,7 +1,40 @@
 package efuse
 
+import "time"
+
 type EFuse interface {
 	GetID() string
 	GetState() (bool, error)
+	State() State
 	PushState(bool) error
+	// PushStateWithLatency is like PushState but also records latency, which factors into
+	// GetState's MaxP95Latency trip check.
+	PushStateWithLatency(state bool, latency time.Duration) error
 }
+
+// State is the trip state of a fuse.
+type State int
+
+const (
+	// StateClosed means the fuse is healthy and calls pass through normally.
+	StateClosed State = iota
+	// StateOpen means the fuse has tripped and calls are rejected.
+	StateOpen
+	// StateHalfOpen means the fuse is probing a limited number of calls to test recovery.
+	StateHalfOpen
+)
+
+// TripReason identifies which configured limit caused a fuse to trip.
+type TripReason int
+
+const (
+	// TripMaxRequest means the fuse tripped because PolyfuseSettings.MaxRequest was reached.
+	TripMaxRequest TripReason = iota
+	// TripMaxError means the fuse tripped because PolyfuseSettings.MaxError was reached.
+	TripMaxError
+	// TripErrorRate means the fuse tripped because PolyfuseSettings.ErrorRate was exceeded.
+	TripErrorRate
+	// TripMaxLatency means the fuse tripped because the decayed p95 latency exceeded
+	// PolyfuseSettings.MaxP95Latency.
+	TripMaxLatency
+)
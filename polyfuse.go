@@ -9,6 +9,16 @@ type PolyfuseData struct {
 	Request   float64   `json:"request"`
 	Error     float64   `json:"error"`
 	Timestamp time.Time `json:"timestamp"`
+
+	OpenedAt       time.Time `json:"opened_at"`       // when the fuse last tripped, zero value means closed
+	ProbeSuccesses int       `json:"probe_successes"` // consecutive successful probes while half-open
+	ProbeInFlight  int       `json:"probe_in_flight"` // probes currently admitted while half-open
+
+	HalfOpenNotified bool `json:"half_open_notified"` // whether OnStateChange already fired for this trip's Open->HalfOpen transition
+
+	// LatencyBuckets is a decayed histogram of recent call latencies, log-spaced across
+	// latencyBucketCount buckets from latencyBucketMin to latencyBucketMax.
+	LatencyBuckets [latencyBucketCount]float64 `json:"latency_buckets"`
 }
 
 type PolifuseStore interface {
@@ -29,11 +39,34 @@ func (s DefaultPolyfuseStore) PushData(d PolyfuseData) error {
 	s.data.Request = d.Request
 	s.data.Error = d.Error
 	s.data.Timestamp = d.Timestamp
+	s.data.OpenedAt = d.OpenedAt
+	s.data.ProbeSuccesses = d.ProbeSuccesses
+	s.data.ProbeInFlight = d.ProbeInFlight
+	s.data.HalfOpenNotified = d.HalfOpenNotified
 	return nil
 }
 
 func (s DefaultPolyfuseStore) GetState(PolyfuseData) (bool, error) { return false, nil }
 
+// AtomicPolifuseStore is a PolifuseStore that can update its counters and trip state directly on
+// the backend, avoiding a fleet-wide read-modify-write race. Polyfuse uses this automatically
+// when the configured store implements it; stores that don't (like DefaultPolyfuseStore) fall
+// back to FetchData/PushData.
+type AtomicPolifuseStore interface {
+	PolifuseStore
+
+	// IncrementRequest snaps the request counter for elapsed time at reqPerSec and adds one,
+	// atomically on the backend.
+	IncrementRequest(reqPerSec float64) (*PolyfuseData, error)
+
+	// IncrementError snaps the error counter for elapsed time at errPerSec and adds one,
+	// atomically on the backend.
+	IncrementError(errPerSec float64) (*PolyfuseData, error)
+
+	// CompareAndSwapState replaces the stored data with next, but only if it still matches prev.
+	CompareAndSwapState(prev, next PolyfuseData) (bool, error)
+}
+
 // PolyfuseSettings setting for Polyfuse
 type PolyfuseSettings struct {
 	ID             string                                           // id of the fuse
@@ -44,6 +77,20 @@ type PolyfuseSettings struct {
 	ErrorRate      int                                              // error rate before tripping, measuring in per 10000 (aka 100.00% precision), <=0 means ulimited
 	MultiLock      bool                                             // specific safe update or not, not yet support
 	UpdateDataFunc func(bool, *PolyfuseData) (*PolyfuseData, error) // data collect and stats should be here
+
+	OpenDuration             time.Duration // how long the fuse stays open before allowing probes, required for half-open recovery
+	HalfOpenMaxProbes        int           // max concurrent probes allowed while half-open, min 1
+	HalfOpenSuccessThreshold int           // consecutive probe successes required to close the fuse, min 1
+
+	MaxP95Latency    time.Duration // trip the fuse if the decayed p95 latency exceeds this, <=0 means unlimited
+	LatencyRampframe int           // rampup time for MaxP95Latency, analogous to Rampframe, recommend 4s-10s, min 1s
+
+	// OnStateChange, if set, is called whenever GetState transitions the fuse from one State to
+	// another, and whenever PushState records a half-open probe result that closes or re-opens it.
+	OnStateChange func(id string, from, to State)
+	// OnTrip, if set, is called whenever GetState trips the fuse, identifying which configured
+	// limit caused it.
+	OnTrip func(id string, reason TripReason)
 }
 
 type Polyfuse struct {
@@ -57,6 +104,20 @@ type Polyfuse struct {
 // GetID return fuse ID
 func (f *Polyfuse) GetID() string { return f.setting.ID }
 
+// State return the current trip state of the fuse without pushing any data to storage
+func (f *Polyfuse) State() State {
+	data, err := f.store.FetchData()
+	if err != nil {
+		return StateClosed
+	}
+
+	// evaluate a copy: DefaultPolyfuseStore.FetchData returns its live internal pointer, and
+	// evaluate mutates it to record a fresh trip.
+	snapshot := *data
+	state, _, _, _, _ := f.evaluate(&snapshot)
+	return state
+}
+
 // GetState return state of the fuse base on setting GetStateFunc(). If GetStateFunc() is not provided, the default function will be used
 func (f *Polyfuse) GetState() (bool, error) {
 	// get fuse data
@@ -64,44 +125,181 @@ func (f *Polyfuse) GetState() (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	// capture before evaluate mutates data in place (OpenedAt, HalfOpenNotified)
+	before := *data
 
-	// snap data
+	state, _, _, from, reason := f.evaluate(data)
+
+	if from != state {
+		if from == StateClosed && state == StateOpen && f.setting.OnTrip != nil {
+			f.setting.OnTrip(f.setting.ID, reason)
+		}
+		if f.setting.OnStateChange != nil {
+			f.setting.OnStateChange(f.setting.ID, from, state)
+		}
+	}
+
+	switch state {
+	case StateOpen:
+		return false, f.pushOrSkip(before, data)
+	case StateHalfOpen:
+		// admit up to HalfOpenMaxProbes concurrent calls to test recovery
+		if data.ProbeInFlight >= f.setting.HalfOpenMaxProbes {
+			return false, f.pushOrSkip(before, data)
+		}
+		data.ProbeInFlight++
+		// admit via CompareAndSwapState, like pushProbeState, so a fleet can't overshoot
+		// HalfOpenMaxProbes; decline on a failed swap rather than risk overshooting.
+		if store, ok := f.store.(AtomicPolifuseStore); ok {
+			swapped, err := store.CompareAndSwapState(before, *data)
+			if err != nil {
+				return false, err
+			}
+			return swapped, nil
+		}
+		return true, f.store.PushData(*data)
+	default:
+		return true, f.pushOrSkip(before, data)
+	}
+}
+
+// pushOrSkip writes data back only if it differs from before, the value actually fetched, going
+// through CompareAndSwapState on an AtomicPolifuseStore rather than a blind PushData.
+func (f *Polyfuse) pushOrSkip(before PolyfuseData, data *PolyfuseData) error {
+	if *data == before {
+		return nil
+	}
+	if store, ok := f.store.(AtomicPolifuseStore); ok {
+		_, err := store.CompareAndSwapState(before, *data)
+		return err
+	}
+	return f.store.PushData(*data)
+}
+
+// evaluate snaps the decayed counters and returns the fuse's current state, along with from,
+// the state it's transitioning from (equal to state when nothing changed). It may set
+// data.OpenedAt to record a fresh trip, or data.HalfOpenNotified the first time it reports the
+// Open->HalfOpen transition. reason identifies which limit caused a fresh trip (meaningless
+// unless from is StateClosed and state is StateOpen); the caller is responsible for persisting
+// data.
+func (f *Polyfuse) evaluate(data *PolyfuseData) (state State, newReq, newErr float64, from State, reason TripReason) {
 	now := time.Now()
+
+	// already tripped: stay open until OpenDuration elapses, then probe
+	if !data.OpenedAt.IsZero() {
+		if now.Sub(data.OpenedAt) < f.setting.OpenDuration {
+			return StateOpen, data.Request, data.Error, StateOpen, 0
+		}
+		if data.HalfOpenNotified {
+			return StateHalfOpen, data.Request, data.Error, StateHalfOpen, 0
+		}
+		// first call to observe the Open->HalfOpen transition since OpenDuration elapsed
+		data.HalfOpenNotified = true
+		return StateHalfOpen, data.Request, data.Error, StateOpen, 0
+	}
+
+	// snap data
 	distance := now.Sub(data.Timestamp)
-	newReq := data.Request - (distance.Seconds() * f.reqPerSec)
+	newReq = data.Request - (distance.Seconds() * f.reqPerSec)
 	if newReq < 1 {
 		newReq = 1
 	}
-	newErr := (data.Error - (distance.Seconds() * f.errPerSec))
+	newErr = data.Error - (distance.Seconds() * f.errPerSec)
 	if newErr < 0 {
 		newErr = 0
 	}
 
+	tripped := false
+
 	// check request limit
 	if f.setting.MaxRequest > 0 && f.setting.MaxRequest <= int(newReq) {
-		return false, nil
+		tripped = true
+		reason = TripMaxRequest
 	}
 
 	// check error limit
 	if f.setting.MaxError > 0 && f.setting.MaxError <= int(newErr) {
-		return false, nil
+		if !tripped {
+			reason = TripMaxError
+		}
+		tripped = true
 	}
 
 	// check error rate
 	if f.setting.ErrorRate > 0 && errorShift(newErr, newReq, float64(f.setting.ErrorRate)/10000, distance.Seconds(), float64(f.setting.Rampframe)) {
-		return false, nil
+		if !tripped {
+			reason = TripErrorRate
+		}
+		tripped = true
+	}
+
+	// check p95 latency
+	if f.setting.MaxP95Latency > 0 {
+		p95 := latencyP95(decayLatencyBuckets(data.LatencyBuckets, distance, f.setting.Timeframe))
+		if p95 > 0 && latencyShift(float64(p95), float64(f.setting.MaxP95Latency), distance.Seconds(), float64(f.setting.LatencyRampframe)) {
+			if !tripped {
+				reason = TripMaxLatency
+			}
+			tripped = true
+		}
 	}
 
-	return true, f.store.PushData(*data)
+	if tripped {
+		data.OpenedAt = now
+		data.HalfOpenNotified = false
+		data.ProbeSuccesses = 0
+		data.ProbeInFlight = 0
+		return StateOpen, newReq, newErr, StateClosed, reason
+	}
+
+	return StateClosed, newReq, newErr, StateClosed, 0
 }
 
 // PushState add a state data then push to storage
 func (f *Polyfuse) PushState(state bool) error {
+	return f.pushState(state, nil)
+}
+
+// PushStateWithLatency is like PushState but also records latency into the decayed latency
+// histogram, which GetState checks against MaxP95Latency.
+func (f *Polyfuse) PushStateWithLatency(state bool, latency time.Duration) error {
+	return f.pushState(state, &latency)
+}
+
+func (f *Polyfuse) pushState(state bool, latency *time.Duration) error {
 	// get fuse data
 	data, err := f.store.FetchData()
 	if err != nil {
 		return err
 	}
+	// capture before any local mutation (recordLatency below), for pushProbeState's
+	// CompareAndSwapState
+	before := *data
+
+	if latency != nil {
+		f.recordLatency(data, *latency)
+	}
+
+	// while half-open, probe results decide whether the fuse closes or re-opens
+	if !data.OpenedAt.IsZero() && time.Now().Sub(data.OpenedAt) >= f.setting.OpenDuration {
+		return f.pushProbeState(state, before, data)
+	}
+
+	// prefer an atomic store's snap-and-increment over the fetch/UpdateDataFunc/push round trip;
+	// it has no latency sample, so fall back to the round trip whenever latency was recorded.
+	if latency == nil {
+		if store, ok := f.store.(AtomicPolifuseStore); ok {
+			if _, err := store.IncrementRequest(f.reqPerSec); err != nil {
+				return err
+			}
+			if !state {
+				if _, err := store.IncrementError(f.errPerSec); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
 
 	// update fuse data
 	data, err = f.setting.UpdateDataFunc(state, data)
@@ -113,13 +311,74 @@ func (f *Polyfuse) PushState(state bool) error {
 	return f.store.PushData(*data)
 }
 
+// recordLatency snaps the decayed latency histogram for elapsed time, the same snap-decay
+// style as Request and Error, then adds one observation to latency's bucket.
+func (f *Polyfuse) recordLatency(data *PolyfuseData, latency time.Duration) {
+	distance := time.Now().Sub(data.Timestamp)
+	data.LatencyBuckets = decayLatencyBuckets(data.LatencyBuckets, distance, f.setting.Timeframe)
+	data.LatencyBuckets[latencyBucketIndex(latency)]++
+}
+
+// pushProbeState records the result of a half-open probe, closing the fuse once
+// HalfOpenSuccessThreshold consecutive successes are reached, or re-opening it on any failure.
+// before is the data as fetched from the store, before any local mutation (e.g. a latency
+// sample).
+func (f *Polyfuse) pushProbeState(state bool, before PolyfuseData, data *PolyfuseData) error {
+	data.ProbeInFlight--
+	if data.ProbeInFlight < 0 {
+		data.ProbeInFlight = 0
+	}
+
+	// only reached once OpenDuration has elapsed (fuse observed as StateHalfOpen), so any
+	// transition a probe result causes is away from there; a success short of
+	// HalfOpenSuccessThreshold leaves the fuse half-open.
+	to := StateHalfOpen
+
+	if !state {
+		data.OpenedAt = time.Now()
+		data.HalfOpenNotified = false
+		data.ProbeSuccesses = 0
+		data.ProbeInFlight = 0
+		to = StateOpen
+	} else {
+		data.ProbeSuccesses++
+		if data.ProbeSuccesses >= f.setting.HalfOpenSuccessThreshold {
+			data.OpenedAt = time.Time{}
+			data.HalfOpenNotified = false
+			data.ProbeSuccesses = 0
+			data.ProbeInFlight = 0
+			data.Request = 1
+			data.Error = 0
+			data.Timestamp = time.Now()
+			to = StateClosed
+		}
+	}
+
+	if to != StateHalfOpen && f.setting.OnStateChange != nil {
+		f.setting.OnStateChange(f.setting.ID, StateHalfOpen, to)
+	}
+
+	// CompareAndSwapState keeps concurrent probe results from clobbering each other when the
+	// store supports it (honouring MultiLock); otherwise fall back to an unconditional write
+	if store, ok := f.store.(AtomicPolifuseStore); ok {
+		_, err := store.CompareAndSwapState(before, *data)
+		return err
+	}
+
+	return f.store.PushData(*data)
+}
+
 func NewPolyfuse(setting PolyfuseSettings) EFuse {
-	var f Polyfuse
-	// init local data
-	// f.data = PolyfuseData{Request: 1, Error: 0, Timestamp: time.Now()}
+	store := DefaultPolyfuseStore{data: &PolyfuseData{Request: 1, Error: 0, Timestamp: time.Now()}}
+	return NewPolyfuseWithStore(setting, store)
+}
 
-	// store
-	f.store = DefaultPolyfuseStore{data: &PolyfuseData{Request: 1, Error: 0, Timestamp: time.Now()}}
+// NewPolyfuseWithStore builds a Polyfuse against a caller-supplied PolifuseStore, such as
+// RedisPolyfuseStore, instead of the in-memory DefaultPolyfuseStore NewPolyfuse uses. This lets
+// callers plug in their own store without monkey-patching NewPolyfuse.
+func NewPolyfuseWithStore(setting PolyfuseSettings, store PolifuseStore) EFuse {
+	var f Polyfuse
+	f.store = store
 
 	// precache rate setting
 	f.reqPerSec = float64(setting.MaxRequest) / float64(setting.Timeframe)
@@ -134,11 +393,22 @@ func NewPolyfuse(setting PolyfuseSettings) EFuse {
 	if setting.Rampframe < 1 {
 		setting.Rampframe = 1
 	}
+	if setting.HalfOpenMaxProbes < 1 {
+		setting.HalfOpenMaxProbes = 1
+	}
+	if setting.HalfOpenSuccessThreshold < 1 {
+		setting.HalfOpenSuccessThreshold = 1
+	}
+	if setting.LatencyRampframe < 1 {
+		setting.LatencyRampframe = 1
+	}
 
 	if setting.UpdateDataFunc == nil {
 		setting.UpdateDataFunc = defaultPolyfuseUpdateData(&f)
 	}
 
+	f.setting = setting
+
 	return &f
 }
 
@@ -177,3 +447,90 @@ func errorShift(err, req, errRate, span, ramp float64) bool {
 	normaliseRate := sigmoidRate*(1-errRate) + errRate
 	return currentRate > normaliseRate
 }
+
+const (
+	// latencyBucketCount is the number of log-spaced latency buckets tracked per fuse.
+	latencyBucketCount = 16
+	// latencyBucketMin and latencyBucketMax bound the log-spaced latency histogram.
+	latencyBucketMin = time.Millisecond
+	latencyBucketMax = 10 * time.Second
+)
+
+// latencyBucketIndex returns the histogram bucket a latency observation falls into, log-spaced
+// between latencyBucketMin and latencyBucketMax.
+func latencyBucketIndex(latency time.Duration) int {
+	if latency <= latencyBucketMin {
+		return 0
+	}
+	if latency >= latencyBucketMax {
+		return latencyBucketCount - 1
+	}
+
+	ratio := math.Log(float64(latency)/float64(latencyBucketMin)) / math.Log(float64(latencyBucketMax)/float64(latencyBucketMin))
+	idx := int(ratio * float64(latencyBucketCount-1))
+	if idx < 0 {
+		idx = 0
+	}
+	if idx > latencyBucketCount-1 {
+		idx = latencyBucketCount - 1
+	}
+	return idx
+}
+
+// latencyBucketUpperBound returns the upper latency bound of bucket i, the inverse of
+// latencyBucketIndex.
+func latencyBucketUpperBound(i int) time.Duration {
+	if i >= latencyBucketCount-1 {
+		return latencyBucketMax
+	}
+
+	ratio := float64(i+1) / float64(latencyBucketCount-1)
+	return time.Duration(float64(latencyBucketMin) * math.Pow(float64(latencyBucketMax)/float64(latencyBucketMin), ratio))
+}
+
+// decayLatencyBuckets snaps each bucket's decayed count for elapsed distance, the same
+// snap-decay style as Request and Error, without mutating buckets in place.
+func decayLatencyBuckets(buckets [latencyBucketCount]float64, distance time.Duration, timeframe int) [latencyBucketCount]float64 {
+	decay := distance.Seconds() / float64(timeframe)
+
+	var out [latencyBucketCount]float64
+	for i, v := range buckets {
+		nv := v - decay
+		if nv < 0 {
+			nv = 0
+		}
+		out[i] = nv
+	}
+	return out
+}
+
+// latencyP95 returns the upper bound of the bucket holding the 95th percentile of a decayed
+// latency histogram, or 0 if it holds no data yet.
+func latencyP95(buckets [latencyBucketCount]float64) time.Duration {
+	var total float64
+	for _, v := range buckets {
+		total += v
+	}
+	if total <= 0 {
+		return 0
+	}
+
+	threshold := total * 0.95
+	var cumulative float64
+	for i, v := range buckets {
+		cumulative += v
+		if cumulative >= threshold {
+			return latencyBucketUpperBound(i)
+		}
+	}
+	return latencyBucketMax
+}
+
+// latencyShift mirrors errorShift's sigmoid ramp but for a p95 latency reading against
+// MaxP95Latency: during the ramp window the trip threshold is inflated well above maxP95 so
+// freshly-observed slow requests don't immediately trip the fuse, then it settles to maxP95.
+func latencyShift(p95, maxP95, span, ramp float64) bool {
+	sigmoidRate := 1 / (1 + math.Pow(math.E, (span*12/ramp)-6))
+	threshold := maxP95 * (1 + sigmoidRate*9)
+	return p95 > threshold
+}
@@ -0,0 +1,41 @@
+package efuse
+
+import (
+	"testing"
+	"time"
+)
+
+// TestPushStateWithLatencyDuringProbeClosesOnSuccess reproduces a successful half-open probe
+// recorded with PushStateWithLatency against an AtomicPolifuseStore: the fuse must still close,
+// even though recordLatency mutates the fetched data before pushProbeState's CompareAndSwapState
+// runs.
+func TestPushStateWithLatencyDuringProbeClosesOnSuccess(t *testing.T) {
+	store := &raceStore{data: PolyfuseData{
+		Request:       1,
+		OpenedAt:      time.Now().Add(-time.Hour),
+		ProbeInFlight: 1,
+		Timestamp:     time.Now(),
+	}}
+	setting := PolyfuseSettings{
+		ID:                       "latency-probe",
+		Rampframe:                1,
+		Timeframe:                10,
+		OpenDuration:             time.Millisecond,
+		HalfOpenMaxProbes:        1,
+		HalfOpenSuccessThreshold: 1,
+	}
+	fuse := NewPolyfuseWithStore(setting, store)
+
+	if err := fuse.PushStateWithLatency(true, 10*time.Millisecond); err != nil {
+		t.Fatalf("PushStateWithLatency() error: %v", err)
+	}
+
+	data, err := store.FetchData()
+	if err != nil {
+		t.Fatalf("FetchData() error: %v", err)
+	}
+	if !data.OpenedAt.IsZero() {
+		t.Fatalf("fuse did not close after a successful probe recorded with latency: OpenedAt = %v, want zero "+
+			"(CompareAndSwapState likely failed because its `before` snapshot already carried the local latency mutation)", data.OpenedAt)
+	}
+}
@@ -0,0 +1,276 @@
+package efuse
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tripSettings() PolyfuseSettings {
+	return PolyfuseSettings{
+		ID:                       "test",
+		Rampframe:                1,
+		Timeframe:                10,
+		MaxRequest:               0,
+		MaxError:                 1,
+		OpenDuration:             time.Minute,
+		HalfOpenMaxProbes:        1,
+		HalfOpenSuccessThreshold: 1,
+	}
+}
+
+// TestStateDoesNotMutateStore guards against State() tripping the fuse as a side effect: it
+// must report the trip-condition state without persisting OpenedAt through a store whose
+// FetchData returns its live internal pointer (as DefaultPolyfuseStore's does).
+func TestStateDoesNotMutateStore(t *testing.T) {
+	fuse := NewPolyfuse(tripSettings()).(*Polyfuse)
+	fuse.store.PushData(PolyfuseData{Request: 1, Error: 2, Timestamp: time.Now()})
+
+	if got := fuse.State(); got != StateOpen {
+		t.Fatalf("State() = %v, want StateOpen", got)
+	}
+
+	data, err := fuse.store.FetchData()
+	if err != nil {
+		t.Fatalf("FetchData() error: %v", err)
+	}
+	if !data.OpenedAt.IsZero() {
+		t.Fatalf("State() mutated stored data: OpenedAt = %v, want zero", data.OpenedAt)
+	}
+
+	// GetState should still trip normally afterwards - State() must not have left stray
+	// partial state behind.
+	ok, err := fuse.GetState()
+	if err != nil {
+		t.Fatalf("GetState() error: %v", err)
+	}
+	if ok {
+		t.Fatalf("GetState() = true, want false (the error count still exceeds MaxError)")
+	}
+}
+
+// raceStore is an AtomicPolifuseStore whose FetchData sleeps briefly after reading, widening
+// the window between a caller's fetch and its CompareAndSwapState so concurrent callers race
+// for real in a test instead of relying on scheduler luck.
+type raceStore struct {
+	mu   sync.Mutex
+	data PolyfuseData
+}
+
+func (s *raceStore) FetchData() (*PolyfuseData, error) {
+	s.mu.Lock()
+	d := s.data
+	s.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	return &d, nil
+}
+
+func (s *raceStore) PushData(d PolyfuseData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = d
+	return nil
+}
+
+func (s *raceStore) GetState(PolyfuseData) (bool, error) { return false, nil }
+
+func (s *raceStore) IncrementRequest(float64) (*PolyfuseData, error) {
+	return nil, errors.New("raceStore: not implemented")
+}
+
+func (s *raceStore) IncrementError(float64) (*PolyfuseData, error) {
+	return nil, errors.New("raceStore: not implemented")
+}
+
+func (s *raceStore) CompareAndSwapState(prev, next PolyfuseData) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data != prev {
+		return false, nil
+	}
+	s.data = next
+	return true, nil
+}
+
+// TestHalfOpenAdmissionUsesCompareAndSwap reproduces a fleet racing to admit a half-open probe:
+// several instances fetch the same pre-admission data before any of them writes, and at most
+// HalfOpenMaxProbes of them must be admitted.
+func TestHalfOpenAdmissionUsesCompareAndSwap(t *testing.T) {
+	store := &raceStore{data: PolyfuseData{
+		Request:  1,
+		OpenedAt: time.Now().Add(-time.Hour),
+	}}
+	setting := PolyfuseSettings{
+		ID:                       "race",
+		Rampframe:                1,
+		Timeframe:                10,
+		OpenDuration:             time.Millisecond,
+		HalfOpenMaxProbes:        1,
+		HalfOpenSuccessThreshold: 1,
+	}
+	fuse := NewPolyfuseWithStore(setting, store)
+
+	const concurrency = 5
+	results := make(chan bool, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := fuse.GetState()
+			if err != nil {
+				t.Errorf("GetState() error: %v", err)
+			}
+			results <- ok
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	admitted := 0
+	for ok := range results {
+		if ok {
+			admitted++
+		}
+	}
+	if admitted > setting.HalfOpenMaxProbes {
+		t.Fatalf("admitted %d concurrent probes, want at most %d", admitted, setting.HalfOpenMaxProbes)
+	}
+	if admitted < 1 {
+		t.Fatalf("admitted 0 probes, want at least 1 (the fuse must still be able to recover)")
+	}
+}
+
+// TestHalfOpenAdmissionRecoversSequentially reproduces a single instance, with no concurrency
+// at all, repeatedly calling GetState() against a store with real CompareAndSwapState
+// value-equality semantics (as RedisPolyfuseStore has under MultiLock). One of the calls must
+// admit a probe so the fuse can ever recover; evaluate's HalfOpenNotified flip must not be
+// captured into the admission CAS's `before` ahead of the store actually persisting it, or
+// every CAS mismatches and the fuse is stuck open forever.
+func TestHalfOpenAdmissionRecoversSequentially(t *testing.T) {
+	store := &raceStore{data: PolyfuseData{
+		Request:  1,
+		OpenedAt: time.Now().Add(-time.Hour),
+	}}
+	setting := PolyfuseSettings{
+		ID:                       "sequential-recovery",
+		Rampframe:                1,
+		Timeframe:                10,
+		OpenDuration:             time.Millisecond,
+		HalfOpenMaxProbes:        1,
+		HalfOpenSuccessThreshold: 1,
+	}
+	fuse := NewPolyfuseWithStore(setting, store)
+
+	for i := 0; i < 20; i++ {
+		ok, err := fuse.GetState()
+		if err != nil {
+			t.Fatalf("GetState() error on call %d: %v", i, err)
+		}
+		if ok {
+			return
+		}
+	}
+	t.Fatalf("GetState() never admitted a probe across 20 sequential calls; the fuse can never recover")
+}
+
+// handoffStore is an AtomicPolifuseStore whose FetchData blocks after reading until the test
+// signals proceed, so a test can deterministically land a write in the middle of a caller's
+// fetch-to-push window instead of racing on sleep durations.
+type handoffStore struct {
+	mu      sync.Mutex
+	data    PolyfuseData
+	fetched chan struct{}
+	proceed chan struct{}
+}
+
+func newHandoffStore(data PolyfuseData) *handoffStore {
+	return &handoffStore{data: data, fetched: make(chan struct{}), proceed: make(chan struct{})}
+}
+
+func (s *handoffStore) FetchData() (*PolyfuseData, error) {
+	s.mu.Lock()
+	d := s.data
+	s.mu.Unlock()
+	close(s.fetched)
+	<-s.proceed
+	return &d, nil
+}
+
+// peek reads the current data directly, bypassing the fetch/proceed handoff.
+func (s *handoffStore) peek() PolyfuseData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data
+}
+
+func (s *handoffStore) PushData(d PolyfuseData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = d
+	return nil
+}
+
+func (s *handoffStore) GetState(PolyfuseData) (bool, error) { return false, nil }
+
+func (s *handoffStore) IncrementRequest(float64) (*PolyfuseData, error) {
+	return nil, errors.New("handoffStore: not implemented")
+}
+
+func (s *handoffStore) IncrementError(float64) (*PolyfuseData, error) {
+	return nil, errors.New("handoffStore: not implemented")
+}
+
+func (s *handoffStore) CompareAndSwapState(prev, next PolyfuseData) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data != prev {
+		return false, nil
+	}
+	s.data = next
+	return true, nil
+}
+
+// TestGetStateOpenDoesNotClobberConcurrentIncrement reproduces a fleet instance observing
+// StateOpen with nothing to record while another instance's IncrementRequest lands in the
+// fetch-to-push window: GetState must not blindly PushData its (now stale) fetched snapshot
+// back over that increment.
+func TestGetStateOpenDoesNotClobberConcurrentIncrement(t *testing.T) {
+	store := newHandoffStore(PolyfuseData{
+		Request:  1,
+		OpenedAt: time.Now(),
+	})
+	setting := PolyfuseSettings{
+		ID:                "open-no-clobber",
+		Rampframe:         1,
+		Timeframe:         10,
+		OpenDuration:      time.Hour,
+		HalfOpenMaxProbes: 1,
+	}
+	fuse := NewPolyfuseWithStore(setting, store)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := fuse.GetState(); err != nil {
+			t.Errorf("GetState() error: %v", err)
+		}
+	}()
+
+	// Wait for GetState's FetchData to have read the pre-increment snapshot, then land a
+	// concurrent increment before letting GetState's push proceed.
+	<-store.fetched
+	want := PolyfuseData{Request: 6, OpenedAt: store.peek().OpenedAt}
+	if err := store.PushData(want); err != nil {
+		t.Fatalf("PushData() error: %v", err)
+	}
+	close(store.proceed)
+
+	<-done
+
+	final := store.peek()
+	if final.Request != want.Request {
+		t.Fatalf("GetState() clobbered a concurrent increment: Request = %v, want %v", final.Request, want.Request)
+	}
+}
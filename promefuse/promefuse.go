@@ -0,0 +1,121 @@
+// Package promefuse exposes Prometheus metrics for efuse fuses, driven by
+// PolyfuseSettings.OnStateChange and PolyfuseSettings.OnTrip so operators can see what their
+// fuses are doing without polling GetState by hand.
+package promefuse
+
+import (
+	"github.com/LibertusDio/efuse"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector holds the Prometheus metrics promefuse registers for a set of fuses.
+type Collector struct {
+	Requests *prometheus.CounterVec
+	Errors   *prometheus.CounterVec
+	State    *prometheus.GaugeVec
+	Trips    *prometheus.CounterVec
+}
+
+// NewCollector builds a Collector with efuse's standard metric names and registers it against
+// reg: efuse_requests_total{id}, efuse_errors_total{id}, efuse_state{id,state} and
+// efuse_trips_total{id,reason}.
+func NewCollector(reg prometheus.Registerer) *Collector {
+	c := &Collector{
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "efuse_requests_total",
+			Help: "Total calls pushed through an efuse fuse.",
+		}, []string{"id"}),
+		Errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "efuse_errors_total",
+			Help: "Total failed calls pushed through an efuse fuse.",
+		}, []string{"id"}),
+		State: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "efuse_state",
+			Help: "Current state of an efuse fuse (1 for the active state, 0 otherwise).",
+		}, []string{"id", "state"}),
+		Trips: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "efuse_trips_total",
+			Help: "Total times an efuse fuse has tripped, by reason.",
+		}, []string{"id", "reason"}),
+	}
+	reg.MustRegister(c.Requests, c.Errors, c.State, c.Trips)
+	return c
+}
+
+// Wrap returns a copy of setting whose OnStateChange and OnTrip update c's metrics for
+// setting.ID, in addition to calling through to any hooks already set on setting. Build the
+// fuse from the returned settings (e.g. via efuse.NewPolyfuse) before registering it with a
+// Registry.
+func (c *Collector) Wrap(setting efuse.PolyfuseSettings) efuse.PolyfuseSettings {
+	prevStateChange := setting.OnStateChange
+	prevTrip := setting.OnTrip
+
+	setting.OnStateChange = func(id string, from, to efuse.State) {
+		c.State.WithLabelValues(id, stateName(from)).Set(0)
+		c.State.WithLabelValues(id, stateName(to)).Set(1)
+		if prevStateChange != nil {
+			prevStateChange(id, from, to)
+		}
+	}
+	setting.OnTrip = func(id string, reason efuse.TripReason) {
+		c.Trips.WithLabelValues(id, reasonName(reason)).Inc()
+		if prevTrip != nil {
+			prevTrip(id, reason)
+		}
+	}
+
+	return setting
+}
+
+// Observe records one call's outcome against id's efuse_requests_total/efuse_errors_total
+// counters. Call it alongside PushState / PushStateWithLatency, e.g. from efuse.HTTPMiddleware
+// or the gRPC interceptors.
+func (c *Collector) Observe(id string, success bool) {
+	c.Requests.WithLabelValues(id).Inc()
+	if !success {
+		c.Errors.WithLabelValues(id).Inc()
+	}
+}
+
+// SnapshotStates sets efuse_state for every fuse in reg to its current State(). It complements
+// Wrap for fuses whose settings weren't built with it, or simply as a periodic correction
+// against any OnStateChange calls a scrape interval might have landed between.
+func (c *Collector) SnapshotStates(reg *efuse.Registry) {
+	for _, f := range reg.List() {
+		id := f.GetID()
+		current := f.State()
+		for _, s := range []efuse.State{efuse.StateClosed, efuse.StateOpen, efuse.StateHalfOpen} {
+			value := 0.0
+			if s == current {
+				value = 1
+			}
+			c.State.WithLabelValues(id, stateName(s)).Set(value)
+		}
+	}
+}
+
+func stateName(s efuse.State) string {
+	switch s {
+	case efuse.StateOpen:
+		return "open"
+	case efuse.StateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+func reasonName(r efuse.TripReason) string {
+	switch r {
+	case efuse.TripMaxRequest:
+		return "max_request"
+	case efuse.TripMaxError:
+		return "max_error"
+	case efuse.TripErrorRate:
+		return "error_rate"
+	case efuse.TripMaxLatency:
+		return "max_latency"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,74 @@
+package promefuse
+
+import (
+	"testing"
+
+	"github.com/LibertusDio/efuse"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectorObserve(t *testing.T) {
+	c := NewCollector(prometheus.NewRegistry())
+
+	c.Observe("svc", true)
+	c.Observe("svc", false)
+
+	if got := testutil.ToFloat64(c.Requests.WithLabelValues("svc")); got != 2 {
+		t.Fatalf("Requests = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(c.Errors.WithLabelValues("svc")); got != 1 {
+		t.Fatalf("Errors = %v, want 1", got)
+	}
+}
+
+func TestCollectorWrapUpdatesStateAndTripMetrics(t *testing.T) {
+	c := NewCollector(prometheus.NewRegistry())
+
+	var prevStateCalls, prevTripCalls int
+	setting := efuse.PolyfuseSettings{
+		ID: "svc",
+		OnStateChange: func(id string, from, to efuse.State) {
+			prevStateCalls++
+		},
+		OnTrip: func(id string, reason efuse.TripReason) {
+			prevTripCalls++
+		},
+	}
+	wrapped := c.Wrap(setting)
+
+	wrapped.OnStateChange("svc", efuse.StateClosed, efuse.StateOpen)
+	wrapped.OnTrip("svc", efuse.TripMaxError)
+
+	if prevStateCalls != 1 || prevTripCalls != 1 {
+		t.Fatalf("prior hooks called %d/%d times, want 1/1", prevStateCalls, prevTripCalls)
+	}
+	if got := testutil.ToFloat64(c.State.WithLabelValues("svc", "closed")); got != 0 {
+		t.Fatalf("State(closed) = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(c.State.WithLabelValues("svc", "open")); got != 1 {
+		t.Fatalf("State(open) = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(c.Trips.WithLabelValues("svc", "max_error")); got != 1 {
+		t.Fatalf("Trips(max_error) = %v, want 1", got)
+	}
+}
+
+func TestCollectorSnapshotStates(t *testing.T) {
+	c := NewCollector(prometheus.NewRegistry())
+
+	reg := efuse.NewRegistry()
+	reg.Register(efuse.NewPolyfuse(efuse.PolyfuseSettings{ID: "svc"}))
+
+	c.SnapshotStates(reg)
+
+	if got := testutil.ToFloat64(c.State.WithLabelValues("svc", "closed")); got != 1 {
+		t.Fatalf("State(closed) = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(c.State.WithLabelValues("svc", "open")); got != 0 {
+		t.Fatalf("State(open) = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(c.State.WithLabelValues("svc", "half_open")); got != 0 {
+		t.Fatalf("State(half_open) = %v, want 0", got)
+	}
+}
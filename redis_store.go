@@ -0,0 +1,234 @@
+package efuse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// redisPolyfuseData is the wire format stored in Redis. Timestamps are kept as Unix
+// nanoseconds rather than PolyfuseData's time.Time so the Lua scripts can do the snap-decay
+// arithmetic with plain numbers.
+type redisPolyfuseData struct {
+	Request          float64                     `json:"request"`
+	Error            float64                     `json:"error"`
+	Timestamp        int64                       `json:"timestamp"`
+	OpenedAt         int64                       `json:"opened_at"`
+	ProbeSuccesses   int                         `json:"probe_successes"`
+	ProbeInFlight    int                         `json:"probe_in_flight"`
+	HalfOpenNotified bool                        `json:"half_open_notified"`
+	LatencyBuckets   [latencyBucketCount]float64 `json:"latency_buckets"`
+}
+
+func toRedisPolyfuseData(d PolyfuseData) redisPolyfuseData {
+	var openedAt int64
+	if !d.OpenedAt.IsZero() {
+		openedAt = d.OpenedAt.UnixNano()
+	}
+	return redisPolyfuseData{
+		Request:          d.Request,
+		Error:            d.Error,
+		Timestamp:        d.Timestamp.UnixNano(),
+		OpenedAt:         openedAt,
+		ProbeSuccesses:   d.ProbeSuccesses,
+		ProbeInFlight:    d.ProbeInFlight,
+		HalfOpenNotified: d.HalfOpenNotified,
+		LatencyBuckets:   d.LatencyBuckets,
+	}
+}
+
+func (d redisPolyfuseData) toPolyfuseData() *PolyfuseData {
+	var openedAt time.Time
+	if d.OpenedAt > 0 {
+		openedAt = time.Unix(0, d.OpenedAt)
+	}
+	return &PolyfuseData{
+		Request:          d.Request,
+		Error:            d.Error,
+		Timestamp:        time.Unix(0, d.Timestamp),
+		OpenedAt:         openedAt,
+		ProbeSuccesses:   d.ProbeSuccesses,
+		ProbeInFlight:    d.ProbeInFlight,
+		HalfOpenNotified: d.HalfOpenNotified,
+		LatencyBuckets:   d.LatencyBuckets,
+	}
+}
+
+// redisIncrementScript snaps the request or error counter for elapsed time then adds one, all
+// atomically on the Redis server so concurrent instances never read-modify-write a stale copy.
+var redisIncrementScript = redis.NewScript(`
+local raw = redis.call('GET', KEYS[1])
+local data
+if raw then
+	data = cjson.decode(raw)
+else
+	data = {request = 1, error = 0, timestamp = tonumber(ARGV[1]), opened_at = 0, probe_successes = 0, probe_in_flight = 0, half_open_notified = false}
+end
+
+local now = tonumber(ARGV[1])
+local perSec = tonumber(ARGV[2])
+local isError = tonumber(ARGV[3])
+local distance = (now - data.timestamp) / 1e9
+
+if isError == 1 then
+	local newErr = data.error - (distance * perSec)
+	if newErr < 0 then newErr = 0 end
+	data.error = newErr + 1
+else
+	local newReq = data.request - (distance * perSec)
+	if newReq < 0 then newReq = 0 end
+	data.request = newReq + 1
+end
+data.timestamp = now
+
+local encoded = cjson.encode(data)
+local ttl = tonumber(ARGV[4])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], encoded, 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], encoded)
+end
+return encoded
+`)
+
+// redisCompareAndSwapScript replaces KEYS[1] with ARGV[2] only if its current value still
+// equals ARGV[1].
+var redisCompareAndSwapScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current ~= ARGV[1] then
+	return 0
+end
+
+local ttl = tonumber(ARGV[3])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// RedisPolyfuseStore is a PolifuseStore (and AtomicPolifuseStore) backed by Redis, letting a
+// fleet of instances share a fuse's state instead of each one tripping independently.
+type RedisPolyfuseStore struct {
+	client *redis.Client
+	id     string
+	ttl    time.Duration
+
+	multiLock bool
+}
+
+// NewRedisPolyfuseStore builds a RedisPolyfuseStore for setting.ID. ttl controls how long the
+// stored data survives without an update before Redis expires the key; pass 0 to never expire
+// it. setting.MultiLock controls whether CompareAndSwapState actually guards against lost
+// updates or degrades to an unconditional write.
+func NewRedisPolyfuseStore(client *redis.Client, setting PolyfuseSettings, ttl time.Duration) *RedisPolyfuseStore {
+	return &RedisPolyfuseStore{
+		client:    client,
+		id:        setting.ID,
+		ttl:       ttl,
+		multiLock: setting.MultiLock,
+	}
+}
+
+func (s *RedisPolyfuseStore) key() string {
+	return fmt.Sprintf("efuse:%s:data", s.id)
+}
+
+func (s *RedisPolyfuseStore) ttlMillis() int64 {
+	if s.ttl <= 0 {
+		return 0
+	}
+	return s.ttl.Milliseconds()
+}
+
+// FetchData returns the stored data for this fuse, or fresh defaults if nothing has been stored
+// yet; those defaults are not written to Redis.
+func (s *RedisPolyfuseStore) FetchData() (*PolyfuseData, error) {
+	ctx := context.Background()
+	raw, err := s.client.Get(ctx, s.key()).Bytes()
+	if err == redis.Nil {
+		return &PolyfuseData{Request: 1, Error: 0, Timestamp: time.Now()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var data redisPolyfuseData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data.toPolyfuseData(), nil
+}
+
+// PushData unconditionally overwrites the stored data.
+func (s *RedisPolyfuseStore) PushData(d PolyfuseData) error {
+	ctx := context.Background()
+	raw, err := json.Marshal(toRedisPolyfuseData(d))
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.key(), raw, s.ttl).Err()
+}
+
+func (s *RedisPolyfuseStore) GetState(PolyfuseData) (bool, error) { return false, nil }
+
+// IncrementRequest snaps the request counter for elapsed time at reqPerSec and adds one,
+// atomically on the Redis server via redisIncrementScript.
+func (s *RedisPolyfuseStore) IncrementRequest(reqPerSec float64) (*PolyfuseData, error) {
+	return s.increment(reqPerSec, false)
+}
+
+// IncrementError snaps the error counter for elapsed time at errPerSec and adds one,
+// atomically on the Redis server via redisIncrementScript.
+func (s *RedisPolyfuseStore) IncrementError(errPerSec float64) (*PolyfuseData, error) {
+	return s.increment(errPerSec, true)
+}
+
+func (s *RedisPolyfuseStore) increment(perSec float64, isError bool) (*PolyfuseData, error) {
+	ctx := context.Background()
+	errFlag := 0
+	if isError {
+		errFlag = 1
+	}
+
+	raw, err := redisIncrementScript.Run(ctx, s.client, []string{s.key()}, time.Now().UnixNano(), perSec, errFlag, s.ttlMillis()).Text()
+	if err != nil {
+		return nil, err
+	}
+
+	var data redisPolyfuseData
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return nil, err
+	}
+	return data.toPolyfuseData(), nil
+}
+
+// CompareAndSwapState atomically replaces the stored data with next, but only if the data
+// currently stored still matches prev. When MultiLock is disabled on the fuse's setting this
+// degrades to an unconditional write of next, matching the historical (not yet supported)
+// behaviour of MultiLock.
+func (s *RedisPolyfuseStore) CompareAndSwapState(prev, next PolyfuseData) (bool, error) {
+	if !s.multiLock {
+		return true, s.PushData(next)
+	}
+
+	ctx := context.Background()
+	prevRaw, err := json.Marshal(toRedisPolyfuseData(prev))
+	if err != nil {
+		return false, err
+	}
+	nextRaw, err := json.Marshal(toRedisPolyfuseData(next))
+	if err != nil {
+		return false, err
+	}
+
+	swapped, err := redisCompareAndSwapScript.Run(ctx, s.client, []string{s.key()}, string(prevRaw), string(nextRaw), s.ttlMillis()).Bool()
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
@@ -0,0 +1,144 @@
+package efuse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestRedisStore(t *testing.T, setting PolyfuseSettings) *RedisPolyfuseStore {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run() error: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return NewRedisPolyfuseStore(client, setting, 0)
+}
+
+func TestRedisPolyfuseStoreFetchDataSeedsDefaults(t *testing.T) {
+	store := newTestRedisStore(t, PolyfuseSettings{ID: "seed"})
+
+	data, err := store.FetchData()
+	if err != nil {
+		t.Fatalf("FetchData() error: %v", err)
+	}
+	if data.Request != 1 || data.Error != 0 {
+		t.Fatalf("FetchData() = %+v, want fresh defaults (Request: 1, Error: 0)", data)
+	}
+}
+
+func TestRedisPolyfuseStorePushAndFetchRoundTrip(t *testing.T) {
+	store := newTestRedisStore(t, PolyfuseSettings{ID: "roundtrip"})
+
+	want := PolyfuseData{
+		Request:          3,
+		Error:            1,
+		Timestamp:        time.Now().Truncate(time.Second),
+		OpenedAt:         time.Now().Add(-time.Minute).Truncate(time.Second),
+		ProbeSuccesses:   2,
+		ProbeInFlight:    1,
+		HalfOpenNotified: true,
+	}
+	if err := store.PushData(want); err != nil {
+		t.Fatalf("PushData() error: %v", err)
+	}
+
+	got, err := store.FetchData()
+	if err != nil {
+		t.Fatalf("FetchData() error: %v", err)
+	}
+	if *got != want {
+		t.Fatalf("FetchData() = %+v, want %+v", *got, want)
+	}
+}
+
+func TestRedisPolyfuseStoreIncrementRequest(t *testing.T) {
+	store := newTestRedisStore(t, PolyfuseSettings{ID: "increment"})
+
+	if err := store.PushData(PolyfuseData{Request: 1, Timestamp: time.Now()}); err != nil {
+		t.Fatalf("PushData() error: %v", err)
+	}
+
+	data, err := store.IncrementRequest(0)
+	if err != nil {
+		t.Fatalf("IncrementRequest() error: %v", err)
+	}
+	if data.Request != 2 {
+		t.Fatalf("IncrementRequest() Request = %v, want 2", data.Request)
+	}
+}
+
+func TestRedisPolyfuseStoreCompareAndSwapState(t *testing.T) {
+	store := newTestRedisStore(t, PolyfuseSettings{ID: "cas", MultiLock: true})
+
+	initial := PolyfuseData{Request: 1, Timestamp: time.Now().Truncate(0)}
+	if err := store.PushData(initial); err != nil {
+		t.Fatalf("PushData() error: %v", err)
+	}
+
+	stale := initial
+	stale.Request = 99
+	next := initial
+	next.Request = 2
+
+	swapped, err := store.CompareAndSwapState(stale, next)
+	if err != nil {
+		t.Fatalf("CompareAndSwapState() error: %v", err)
+	}
+	if swapped {
+		t.Fatalf("CompareAndSwapState() swapped against a stale prev, want rejected")
+	}
+
+	swapped, err = store.CompareAndSwapState(initial, next)
+	if err != nil {
+		t.Fatalf("CompareAndSwapState() error: %v", err)
+	}
+	if !swapped {
+		t.Fatalf("CompareAndSwapState() did not swap against the current prev, want accepted")
+	}
+
+	got, err := store.FetchData()
+	if err != nil {
+		t.Fatalf("FetchData() error: %v", err)
+	}
+	if *got != next {
+		t.Fatalf("FetchData() = %+v, want %+v", *got, next)
+	}
+}
+
+func TestRedisPolyfuseStoreCompareAndSwapStateWithoutMultiLockAlwaysWrites(t *testing.T) {
+	store := newTestRedisStore(t, PolyfuseSettings{ID: "no-multilock", MultiLock: false})
+
+	initial := PolyfuseData{Request: 1, Timestamp: time.Now().Truncate(0)}
+	if err := store.PushData(initial); err != nil {
+		t.Fatalf("PushData() error: %v", err)
+	}
+
+	stale := initial
+	stale.Request = 99
+	next := initial
+	next.Request = 2
+
+	swapped, err := store.CompareAndSwapState(stale, next)
+	if err != nil {
+		t.Fatalf("CompareAndSwapState() error: %v", err)
+	}
+	if !swapped {
+		t.Fatalf("CompareAndSwapState() without MultiLock should unconditionally write, got rejected")
+	}
+
+	got, err := store.FetchData()
+	if err != nil {
+		t.Fatalf("FetchData() error: %v", err)
+	}
+	if *got != next {
+		t.Fatalf("FetchData() = %+v, want %+v", *got, next)
+	}
+}
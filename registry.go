@@ -0,0 +1,43 @@
+package efuse
+
+import "sync"
+
+// Registry is a concurrency-safe collection of EFuse instances keyed by GetID. A service
+// registers its fuses once and looks them up by ID at call sites (HTTPMiddleware, the gRPC
+// interceptors, an admin endpoint) instead of threading them through by hand.
+type Registry struct {
+	mu    sync.RWMutex
+	fuses map[string]EFuse
+}
+
+// NewRegistry returns an empty Registry ready to use.
+func NewRegistry() *Registry {
+	return &Registry{fuses: make(map[string]EFuse)}
+}
+
+// Register adds f to the registry, keyed by f.GetID(). Registering a fuse with an ID already
+// present replaces the previous entry.
+func (r *Registry) Register(f EFuse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fuses[f.GetID()] = f
+}
+
+// Get returns the fuse registered under id, or nil if none is registered.
+func (r *Registry) Get(id string) EFuse {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.fuses[id]
+}
+
+// List returns every registered fuse, in no particular order.
+func (r *Registry) List() []EFuse {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]EFuse, 0, len(r.fuses))
+	for _, f := range r.fuses {
+		out = append(out, f)
+	}
+	return out
+}
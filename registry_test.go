@@ -0,0 +1,36 @@
+package efuse
+
+import "testing"
+
+func TestRegistryRegisterGetList(t *testing.T) {
+	reg := NewRegistry()
+
+	if got := reg.Get("missing"); got != nil {
+		t.Fatalf("Get(%q) = %v, want nil", "missing", got)
+	}
+	if got := reg.List(); len(got) != 0 {
+		t.Fatalf("List() = %v, want empty", got)
+	}
+
+	a := NewPolyfuse(PolyfuseSettings{ID: "a"})
+	b := NewPolyfuse(PolyfuseSettings{ID: "b"})
+	reg.Register(a)
+	reg.Register(b)
+
+	if got := reg.Get("a"); got != a {
+		t.Fatalf("Get(%q) = %v, want %v", "a", got, a)
+	}
+	if got := reg.List(); len(got) != 2 {
+		t.Fatalf("List() returned %d fuses, want 2", len(got))
+	}
+
+	// Registering a second fuse under an ID already present replaces the previous entry.
+	replacement := NewPolyfuse(PolyfuseSettings{ID: "a"})
+	reg.Register(replacement)
+	if got := reg.Get("a"); got != replacement {
+		t.Fatalf("Get(%q) = %v, want %v after replace", "a", got, replacement)
+	}
+	if got := reg.List(); len(got) != 2 {
+		t.Fatalf("List() returned %d fuses after replace, want 2", len(got))
+	}
+}